@@ -16,6 +16,8 @@ package json
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/juju/errors"
@@ -57,9 +59,10 @@ func (j JSON) Extract(pathExprList []PathExpression) (ret JSON, found bool) {
 	}
 	if len(elemList) == 0 {
 		found = false
-	} else if len(pathExprList) == 1 && len(elemList) == 1 {
-		// If pathExpr contains asterisks, len(elemList) won't be 1
-		// even if len(pathExprList) equals to 1.
+	} else if len(pathExprList) == 1 && len(elemList) == 1 && !pathExprList[0].flags.containsAnyAsterisk() {
+		// If pathExpr contains asterisks or filters, the result is always
+		// autowrapped as an array, even when len(elemList) happens to equal 1;
+		// a filter leg is treated the same way a '*' wildcard is for this purpose.
 		found = true
 		ret = elemList[0]
 	} else {
@@ -106,14 +109,14 @@ func unquoteString(s string) (string, error) {
 			case '\\':
 				ret.WriteByte('\\')
 			case 'u':
-				if i+4 >= len(s) {
-					return "", errors.New("Invalid unicode")
+				unicode, size, err := decodeEscapedUnicode(s, i)
+				if err != nil {
+					return "", errors.Trace(err)
 				}
-				unicode, size := utf8.DecodeRuneInString(s[i-1 : i+5])
-				utf8Buf := make([]byte, size)
-				utf8.EncodeRune(utf8Buf, unicode)
-				ret.Write(utf8Buf)
-				i += 4
+				utf8Buf := make([]byte, utf8.UTFMax)
+				n := utf8.EncodeRune(utf8Buf, unicode)
+				ret.Write(utf8Buf[:n])
+				i += size
 			default:
 				ret.WriteByte(s[i])
 			}
@@ -124,6 +127,48 @@ func unquoteString(s string) (string, error) {
 	return ret.String(), nil
 }
 
+// decodeEscapedUnicode decodes the '\uXXXX' escape starting at s[i] == 'u',
+// transparently combining it with a following '\uXXXX' low surrogate when it
+// is a high surrogate. It returns the decoded rune and the number of bytes
+// of the escape that follow s[i] (4 for a lone codepoint, 10 for a
+// surrogate pair).
+func decodeEscapedUnicode(s string, i int) (r rune, size int, err error) {
+	hi, err := parseHex4(s, i+1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hi < 0xD800 || hi > 0xDFFF {
+		return rune(hi), 4, nil
+	}
+	if hi > 0xDBFF {
+		// a low surrogate cannot appear on its own.
+		return 0, 0, errors.New("Invalid unicode surrogate pair")
+	}
+	if i+6 >= len(s) || s[i+5] != '\\' || s[i+6] != 'u' {
+		return 0, 0, errors.New("Invalid unicode surrogate pair")
+	}
+	lo, err := parseHex4(s, i+7)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lo < 0xDC00 || lo > 0xDFFF {
+		return 0, 0, errors.New("Invalid unicode surrogate pair")
+	}
+	return 0x10000 + rune(hi-0xD800)*0x400 + rune(lo-0xDC00), 10, nil
+}
+
+// parseHex4 parses the four hex digits starting at s[start].
+func parseHex4(s string, start int) (uint32, error) {
+	if start+4 > len(s) {
+		return 0, errors.New("Invalid unicode")
+	}
+	v, err := strconv.ParseUint(s[start:start+4], 16, 32)
+	if err != nil {
+		return 0, errors.New("Invalid unicode")
+	}
+	return uint32(v), nil
+}
+
 // extract is used by Extract.
 // NOTE: the return value will share something with j.
 func extract(j JSON, pathExpr PathExpression) (ret []JSON) {
@@ -162,10 +207,102 @@ func extract(j JSON, pathExpr PathExpression) (ret []JSON) {
 				ret = append(ret, extract(j.object[child], pathExpr)...)
 			}
 		}
+	} else if currentLeg.typ == pathLegFilter && j.typeCode == typeCodeArray {
+		for _, child := range j.array {
+			if !matchPathFilter(child, currentLeg.filter) {
+				continue
+			}
+			ret = append(ret, extract(child, subPathExpr)...)
+			if !currentLeg.filter.matchAll {
+				break
+			}
+		}
 	}
 	return
 }
 
+// matchPathFilter reports whether child satisfies a '#(predicate)' filter.
+// When filter.keyPath is empty the predicate compares against child itself
+// (the '@' form), otherwise it navigates child as a nested object.
+func matchPathFilter(child JSON, filter *pathFilter) bool {
+	target := child
+	for _, key := range filter.keyPath {
+		if target.typeCode != typeCodeObject {
+			return false
+		}
+		next, ok := target.object[key]
+		if !ok {
+			return false
+		}
+		target = next
+	}
+	cmp, ok := compareJSON(target, filter.value)
+	if !ok {
+		return false
+	}
+	if target.typeCode == typeCodeLiteral && filter.op != compareOpEQ && filter.op != compareOpNE {
+		// booleans and null have no order.
+		return false
+	}
+	switch filter.op {
+	case compareOpEQ:
+		return cmp == 0
+	case compareOpNE:
+		return cmp != 0
+	case compareOpLT:
+		return cmp < 0
+	case compareOpLE:
+		return cmp <= 0
+	case compareOpGT:
+		return cmp > 0
+	case compareOpGE:
+		return cmp >= 0
+	}
+	return false
+}
+
+// compareJSON compares two scalar JSON values. ok is false when a and b are
+// not of directly comparable kinds (e.g. a number against a string).
+func compareJSON(a, b JSON) (cmp int, ok bool) {
+	if an, aIsNum := asFloat64(a); aIsNum {
+		if bn, bIsNum := asFloat64(b); bIsNum {
+			switch {
+			case an < bn:
+				return -1, true
+			case an > bn:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		return 0, false
+	}
+	if a.typeCode == typeCodeString && b.typeCode == typeCodeString {
+		return strings.Compare(a.str, b.str), true
+	}
+	if a.typeCode == typeCodeLiteral && b.typeCode == typeCodeLiteral {
+		// booleans and null only support equality; report any difference as
+		// "not equal" so == and != behave correctly, ordering operators
+		// simply won't match.
+		if a.i64 == b.i64 {
+			return 0, true
+		}
+		return -1, true
+	}
+	return 0, false
+}
+
+// asFloat64 returns the numeric value of j and whether j holds a number.
+func asFloat64(j JSON) (float64, bool) {
+	switch j.typeCode {
+	case typeCodeInt64:
+		return float64(j.i64), true
+	case typeCodeFloat64:
+		return j.f64, true
+	}
+	return 0, false
+}
+
 // Merge merges suffixes into j according the following rules:
 // 1) adjacent arrays are merged to a single array;
 // 2) adjacent object are merged to a single object;
@@ -212,6 +349,62 @@ func (j *JSON) Merge(suffixes []JSON) {
 	return
 }
 
+// MergePatch merges patches into j following RFC 7396 (JSON Merge Patch)
+// semantics, matching MySQL 8.0's JSON_MERGE_PATCH. Unlike Merge, which
+// implements the MySQL 5.7 rule-based algorithm, MergePatch recursively
+// merges objects key by key, and a JSON null in the patch deletes the
+// corresponding key from the target instead of being stored.
+func (j *JSON) MergePatch(patches []JSON) {
+	for _, patch := range patches {
+		*j = mergePatch(*j, patch)
+	}
+}
+
+// mergePatch implements the recursive algorithm described by RFC 7396:
+// https://tools.ietf.org/html/rfc7396
+//
+//	define MergePatch(Target, Patch):
+//	  if Patch is an Object:
+//	    if Target is not an Object:
+//	      Target = {}
+//	    for each Name/Value pair in Patch:
+//	      if Value is null:
+//	        if Name exists in Target:
+//	          remove the Name/Value pair from Target
+//	      else:
+//	        Target[Name] = MergePatch(Target[Name], Value)
+//	    return Target
+//	  else:
+//	    return Patch
+func mergePatch(target, patch JSON) JSON {
+	if patch.typeCode != typeCodeObject {
+		// arrays and scalars are always replaced, never merged.
+		return patch
+	}
+	if target.typeCode != typeCodeObject {
+		target = CreateJSON(nil)
+		target.typeCode = typeCodeObject
+		target.object = make(map[string]JSON)
+	}
+	for key, value := range patch.object {
+		if isJSONNull(value) {
+			delete(target.object, key)
+			continue
+		}
+		child, ok := target.object[key]
+		if !ok {
+			child = CreateJSON(nil)
+		}
+		target.object[key] = mergePatch(child, value)
+	}
+	return target
+}
+
+// isJSONNull reports whether j is the JSON null literal.
+func isJSONNull(j JSON) bool {
+	return j.typeCode == typeCodeLiteral && byte(j.i64) == jsonLiteralNil
+}
+
 // ModifyType is for modify a JSON. There are three valid values:
 // ModifyInsert, ModifyReplace and ModifySet.
 type ModifyType byte
@@ -266,3 +459,192 @@ func set(j JSON, pathExpr PathExpression, value JSON, mt ModifyType) JSON {
 	}
 	return j
 }
+
+// Remove removes the JSON elements located by pathExprList, implementing
+// MySQL's JSON_REMOVE. Paths cannot contain * or ** wildcards, and the empty
+// '$' path is rejected since there is nothing to remove it from. Paths are
+// evaluated left-to-right, so a later path sees the effect of any removal
+// performed by an earlier one: removing two sibling array indexes in one
+// call shifts the second index's target down by one once the first is
+// spliced out.
+func (j *JSON) Remove(pathExprList []PathExpression) error {
+	for _, pathExpr := range pathExprList {
+		if pathExpr.flags.containsAnyAsterisk() {
+			// TODO should return 3149(42000)
+			return errors.New("Invalid path expression")
+		}
+		if len(pathExpr.legs) == 0 {
+			// TODO should return 3153(42000)
+			return errors.New("The path expression '$' is not allowed in this context")
+		}
+		*j = remove(*j, pathExpr)
+	}
+	return nil
+}
+
+// remove is used by Remove.
+func remove(j JSON, pathExpr PathExpression) JSON {
+	currentLeg, subPathExpr := pathExpr.popOneLeg()
+	if currentLeg.typ == pathLegIndex && j.typeCode == typeCodeArray {
+		index := currentLeg.arrayIndex
+		if index >= len(j.array) {
+			return j
+		}
+		if len(subPathExpr.legs) == 0 {
+			j.array = append(j.array[:index], j.array[index+1:]...)
+		} else {
+			j.array[index] = remove(j.array[index], subPathExpr)
+		}
+	} else if currentLeg.typ == pathLegKey && j.typeCode == typeCodeObject {
+		key := currentLeg.dotKey
+		child, ok := j.object[key]
+		if !ok {
+			return j
+		}
+		if len(subPathExpr.legs) == 0 {
+			delete(j.object, key)
+		} else {
+			j.object[key] = remove(child, subPathExpr)
+		}
+	}
+	return j
+}
+
+// Project returns a pruned version of j containing only the subtrees named
+// by pathExprList, with the original object/array shape preserved. Unlike
+// Extract, which flattens matches into a single value or an array of
+// matches, Project keeps each matched value at its original location, so
+// the result has the same shape as (a subset of) the source document -
+// analogous to a protobuf FieldMask-driven partial response.
+// NOTE: as with Extract, the returned JSON shares matched objects/arrays
+// with the receiver; callers that need an independent copy must clone it.
+func (j JSON) Project(pathExprList []PathExpression) JSON {
+	var ret JSON
+	switch j.typeCode {
+	case typeCodeObject:
+		ret = ensureObject(CreateJSON(nil))
+	case typeCodeArray:
+		ret = ensureArray(CreateJSON(nil))
+	default:
+		ret = CreateJSON(nil)
+	}
+	for _, pathExpr := range pathExprList {
+		ret = project(j, ret, pathExpr)
+	}
+	return ret
+}
+
+// project walks src and dst in lockstep along pathExpr, copying the
+// subtrees of src matched by pathExpr into dst and returning the (possibly
+// freshly allocated) dst. When dst already holds a subtree reached by an
+// earlier path expression, the two are merged by projectMerge instead of
+// one replacing the other.
+func project(src, dst JSON, pathExpr PathExpression) JSON {
+	if len(pathExpr.legs) == 0 {
+		return projectMerge(dst, src)
+	}
+	currentLeg, subPathExpr := pathExpr.popOneLeg()
+	switch currentLeg.typ {
+	case pathLegKey:
+		if src.typeCode != typeCodeObject {
+			return dst
+		}
+		dst = ensureObject(dst)
+		if currentLeg.dotKey == "*" {
+			for key, child := range src.object {
+				dst.object[key] = project(child, childOrNull(dst.object, key), subPathExpr)
+			}
+		} else if child, ok := src.object[currentLeg.dotKey]; ok {
+			dst.object[currentLeg.dotKey] = project(child, childOrNull(dst.object, currentLeg.dotKey), subPathExpr)
+		}
+	case pathLegIndex:
+		if src.typeCode != typeCodeArray {
+			return dst
+		}
+		dst = ensureArray(dst)
+		if currentLeg.arrayIndex == arrayIndexAsterisk {
+			growArray(&dst, len(src.array)-1)
+			for i, child := range src.array {
+				dst.array[i] = project(child, dst.array[i], subPathExpr)
+			}
+		} else if currentLeg.arrayIndex < len(src.array) {
+			growArray(&dst, currentLeg.arrayIndex)
+			dst.array[currentLeg.arrayIndex] = project(src.array[currentLeg.arrayIndex], dst.array[currentLeg.arrayIndex], subPathExpr)
+		}
+	case pathLegDoubleAsterisk:
+		dst = project(src, dst, subPathExpr)
+		if src.typeCode == typeCodeArray {
+			dst = ensureArray(dst)
+			growArray(&dst, len(src.array)-1)
+			for i, child := range src.array {
+				dst.array[i] = project(child, dst.array[i], pathExpr)
+			}
+		} else if src.typeCode == typeCodeObject {
+			dst = ensureObject(dst)
+			for key, child := range src.object {
+				dst.object[key] = project(child, childOrNull(dst.object, key), pathExpr)
+			}
+		}
+	}
+	return dst
+}
+
+// projectMerge merges src into dst, taking the union when the same subtree
+// is reached by more than one path expression.
+func projectMerge(dst, src JSON) JSON {
+	if isJSONNull(dst) {
+		return src
+	}
+	if dst.typeCode == typeCodeObject && src.typeCode == typeCodeObject {
+		for key, child := range src.object {
+			dst.object[key] = projectMerge(childOrNull(dst.object, key), child)
+		}
+		return dst
+	}
+	if dst.typeCode == typeCodeArray && src.typeCode == typeCodeArray {
+		growArray(&dst, len(src.array)-1)
+		for i, child := range src.array {
+			dst.array[i] = projectMerge(dst.array[i], child)
+		}
+		return dst
+	}
+	return src
+}
+
+// ensureObject returns j if it is already an object, otherwise a fresh empty
+// object.
+func ensureObject(j JSON) JSON {
+	if j.typeCode == typeCodeObject {
+		return j
+	}
+	j = CreateJSON(nil)
+	j.typeCode = typeCodeObject
+	j.object = make(map[string]JSON)
+	return j
+}
+
+// ensureArray returns j if it is already an array, otherwise a fresh empty
+// array.
+func ensureArray(j JSON) JSON {
+	if j.typeCode == typeCodeArray {
+		return j
+	}
+	j = CreateJSON(nil)
+	j.typeCode = typeCodeArray
+	return j
+}
+
+// growArray pads dst.array with JSON null up to and including index n.
+func growArray(dst *JSON, n int) {
+	for len(dst.array) <= n {
+		dst.array = append(dst.array, CreateJSON(nil))
+	}
+}
+
+// childOrNull returns m[key], or the JSON null literal if key is absent.
+func childOrNull(m map[string]JSON, key string) JSON {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	return CreateJSON(nil)
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnquoteStringASCII(t *testing.T) {
+	got, err := unquoteString(`hello\tworld`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello\tworld"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnquoteStringBMP(t *testing.T) {
+	// U+00E9 LATIN SMALL LETTER E WITH ACUTE, well within the BMP.
+	got, err := unquoteString("\\u00e9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "é"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnquoteStringAstralPlane(t *testing.T) {
+	// U+1F600 GRINNING FACE, outside the BMP, encoded as the UTF-16
+	// surrogate pair D83D DE00.
+	got, err := unquoteString("\\uD83D\\uDE00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\U0001F600"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnquoteStringMalformedUnicode(t *testing.T) {
+	cases := []string{
+		`\uD83D`,     // high surrogate with no following low surrogate
+		`\uD83DXXXX`, // high surrogate followed by something that isn't \u
+		`\uDE00`,     // orphan low surrogate, never valid on its own
+		`\uZZZZ`,     // non-hex digits
+		`\u12`,       // fewer than four hex digits
+	}
+	for _, c := range cases {
+		if _, err := unquoteString(c); err == nil {
+			t.Fatalf("unquoteString(%q): expected an error, got none", c)
+		}
+	}
+}
+
+func TestMergePatchRFC7396Examples(t *testing.T) {
+	cases := []struct {
+		target JSON
+		patch  JSON
+		want   JSON
+	}{
+		// {"a":"b"} merge-patch {"a":"c"} => {"a":"c"}
+		{
+			mkObject(map[string]JSON{"a": CreateJSON("b")}),
+			mkObject(map[string]JSON{"a": CreateJSON("c")}),
+			mkObject(map[string]JSON{"a": CreateJSON("c")}),
+		},
+		// {"a":"b"} merge-patch {"b":"c"} => {"a":"b","b":"c"}
+		{
+			mkObject(map[string]JSON{"a": CreateJSON("b")}),
+			mkObject(map[string]JSON{"b": CreateJSON("c")}),
+			mkObject(map[string]JSON{"a": CreateJSON("b"), "b": CreateJSON("c")}),
+		},
+		// {"a":"b"} merge-patch {"a":null} => {}
+		{
+			mkObject(map[string]JSON{"a": CreateJSON("b")}),
+			mkObject(map[string]JSON{"a": CreateJSON(nil)}),
+			mkObject(map[string]JSON{}),
+		},
+		// {"a":"b","b":"c"} merge-patch {"a":null} => {"b":"c"}
+		{
+			mkObject(map[string]JSON{"a": CreateJSON("b"), "b": CreateJSON("c")}),
+			mkObject(map[string]JSON{"a": CreateJSON(nil)}),
+			mkObject(map[string]JSON{"b": CreateJSON("c")}),
+		},
+		// {"a":{"b":"c"}} merge-patch {"a":{"b":"d","c":null}} => {"a":{"b":"d"}}
+		{
+			mkObject(map[string]JSON{"a": mkObject(map[string]JSON{"b": CreateJSON("c")})}),
+			mkObject(map[string]JSON{"a": mkObject(map[string]JSON{"b": CreateJSON("d"), "c": CreateJSON(nil)})}),
+			mkObject(map[string]JSON{"a": mkObject(map[string]JSON{"b": CreateJSON("d")})}),
+		},
+	}
+	for i, c := range cases {
+		target := c.target
+		target.MergePatch([]JSON{c.patch})
+		if !reflect.DeepEqual(target, c.want) {
+			t.Fatalf("case %d: got %+v, want %+v", i, target, c.want)
+		}
+	}
+}
+
+func TestMergePatchArraysAreReplacedNotMerged(t *testing.T) {
+	// RFC 7396: "If the provided merge patch contains members that do not
+	// appear within the target, those members are added. [...] Arrays are
+	// not merged, they are replaced."
+	target := mkObject(map[string]JSON{"a": mkArray(mkObject(map[string]JSON{"b": CreateJSON("c")}))})
+	target.MergePatch([]JSON{mkObject(map[string]JSON{"a": mkArray(CreateJSON(1.0))})})
+	want := mkObject(map[string]JSON{"a": mkArray(CreateJSON(1.0))})
+	if !reflect.DeepEqual(target, want) {
+		t.Fatalf("got %+v, want %+v", target, want)
+	}
+}
+
+func TestMergePatchNullDeleteOfAbsentKeyIsNoop(t *testing.T) {
+	target := mkObject(map[string]JSON{"a": CreateJSON("b")})
+	target.MergePatch([]JSON{mkObject(map[string]JSON{"x": CreateJSON(nil)})})
+	want := mkObject(map[string]JSON{"a": CreateJSON("b")})
+	if !reflect.DeepEqual(target, want) {
+		t.Fatalf("got %+v, want %+v", target, want)
+	}
+}
+
+func TestRemoveSiblingArrayIndexesShiftLeftToRight(t *testing.T) {
+	// Paths are evaluated left-to-right, so removing index 0 first shifts
+	// every later element down by one before the second path is applied.
+	root := mkArray(CreateJSON("a"), CreateJSON("b"), CreateJSON("c"))
+	pe0 := mustParsePathExpr(t, `$[0]`)
+	pe1 := mustParsePathExpr(t, `$[1]`)
+	if err := root.Remove([]PathExpression{pe0, pe1}); err != nil {
+		t.Fatal(err)
+	}
+	// after removing $[0] ("a"), the array is ["b","c"]; removing $[1] of
+	// that then deletes "c", leaving only "b" - not "a" and "c" as a
+	// naive "remove both original elements" reading might expect.
+	want := mkArray(CreateJSON("b"))
+	if !reflect.DeepEqual(root, want) {
+		t.Fatalf("got %+v, want %+v", root, want)
+	}
+}
+
+func TestProjectPreservesShape(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"a": CreateJSON("x"),
+		"b": mkObject(map[string]JSON{"c": CreateJSON("y"), "d": CreateJSON("z")}),
+		"e": mkArray(CreateJSON("p"), CreateJSON("q")),
+	})
+	peA := mustParsePathExpr(t, `$.a`)
+	peBC := mustParsePathExpr(t, `$.b.c`)
+	got := root.Project([]PathExpression{peA, peBC})
+
+	want := mkObject(map[string]JSON{
+		"a": CreateJSON("x"),
+		"b": mkObject(map[string]JSON{"c": CreateJSON("y")}),
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProjectArrayIndexIsPaddedWithNull(t *testing.T) {
+	root := mkArray(CreateJSON("a"), CreateJSON("b"), CreateJSON("c"))
+	pe := mustParsePathExpr(t, `$[2]`)
+	got := root.Project([]PathExpression{pe})
+	want := mkArray(CreateJSON(nil), CreateJSON(nil), CreateJSON("c"))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProjectUnionMergesOverlappingPaths(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"b": mkObject(map[string]JSON{"c": CreateJSON("y"), "d": CreateJSON("z")}),
+	})
+	peBC := mustParsePathExpr(t, `$.b.c`)
+	peBD := mustParsePathExpr(t, `$.b.d`)
+	got := root.Project([]PathExpression{peBC, peBD})
+	want := mkObject(map[string]JSON{
+		"b": mkObject(map[string]JSON{"c": CreateJSON("y"), "d": CreateJSON("z")}),
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProjectWildcardFansOutOverArrayElements(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"items": mkArray(
+			mkObject(map[string]JSON{"name": CreateJSON("n1"), "price": CreateJSON("10")}),
+			mkObject(map[string]JSON{"name": CreateJSON("n2"), "price": CreateJSON("20")}),
+		),
+	})
+	pe := mustParsePathExpr(t, `$.items[*].name`)
+	got := root.Project([]PathExpression{pe})
+	want := mkObject(map[string]JSON{
+		"items": mkArray(
+			mkObject(map[string]JSON{"name": CreateJSON("n1")}),
+			mkObject(map[string]JSON{"name": CreateJSON("n2")}),
+		),
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
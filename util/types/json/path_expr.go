@@ -0,0 +1,291 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// pathLegType is the type of a PathExpression leg.
+type pathLegType byte
+
+const (
+	// pathLegKey indicates the path leg with '.key'.
+	pathLegKey pathLegType = iota
+	// pathLegIndex indicates the path leg with '[number]'.
+	pathLegIndex
+	// pathLegDoubleAsterisk indicates the path leg with '**'.
+	pathLegDoubleAsterisk
+	// pathLegFilter indicates the path leg with '#(predicate)' or '#(predicate)#',
+	// a gjson-style array filter.
+	pathLegFilter
+)
+
+// arrayIndexAsterisk is for the array index '*'.
+const arrayIndexAsterisk = -1
+
+// compareOp is the comparison operator of a pathFilter predicate.
+type compareOp byte
+
+const (
+	compareOpEQ compareOp = iota
+	compareOpNE
+	compareOpLT
+	compareOpLE
+	compareOpGT
+	compareOpGE
+)
+
+// pathFilter is the predicate AST carried by a pathLegFilter leg, e.g. the
+// parsed form of `category=="book"` in `$.items.#(category=="book")`.
+type pathFilter struct {
+	keyPath  []string // dot-separated key path relative to the array element; empty means the element ('@') itself.
+	op       compareOp
+	value    JSON
+	matchAll bool // true for the trailing '#' variant: match every element instead of only the first.
+}
+
+// pathLeg is only used by PathExpression.
+type pathLeg struct {
+	typ        pathLegType
+	arrayIndex int         // used by pathLegIndex, either arrayIndexAsterisk or a non-negative number.
+	dotKey     string      // used by pathLegKey, the key name (or "*").
+	filter     *pathFilter // used by pathLegFilter.
+}
+
+// pathExpressionFlag holds attributes of a PathExpression.
+type pathExpressionFlag byte
+
+const (
+	pathExpressionContainsAsterisk       pathExpressionFlag = 1 << iota // if path contains '*'.
+	pathExpressionContainsDoubleAsterisk                                // if path contains '**'.
+)
+
+// containsAnyAsterisk returns true if pef contains any kind of asterisk.
+// A filter leg is treated the same way a '*' wildcard is: it can match more
+// than one element, so callers that reject wildcards (e.g. SetInsertReplace)
+// must reject filters too.
+func (pef pathExpressionFlag) containsAnyAsterisk() bool {
+	pef &= pathExpressionContainsAsterisk | pathExpressionContainsDoubleAsterisk
+	return byte(pef) != 0
+}
+
+// PathExpression is for JSON path expression.
+type PathExpression struct {
+	legs  []pathLeg
+	flags pathExpressionFlag
+}
+
+// popOneLeg returns the first leg and the rest of legs.
+func (pe PathExpression) popOneLeg() (pathLeg, PathExpression) {
+	newPe := PathExpression{
+		legs: pe.legs[1:],
+	}
+	for _, leg := range newPe.legs {
+		switch {
+		case leg.typ == pathLegIndex && leg.arrayIndex == arrayIndexAsterisk:
+			newPe.flags |= pathExpressionContainsAsterisk
+		case leg.typ == pathLegKey && leg.dotKey == "*":
+			newPe.flags |= pathExpressionContainsAsterisk
+		case leg.typ == pathLegDoubleAsterisk:
+			newPe.flags |= pathExpressionContainsDoubleAsterisk
+		case leg.typ == pathLegFilter:
+			newPe.flags |= pathExpressionContainsAsterisk
+		}
+	}
+	return pe.legs[0], newPe
+}
+
+// pathExprLegRe tokenizes one leg at a time. Exactly one of the named groups
+// fires per match:
+//
+//	key        a '.key', '.*' or '."quoted key"' leg
+//	idx        the 'N' or '*' inside a '[N]'/'[*]' leg
+//	dblstar    the literal '**'
+//	pred1      the predicate inside a '.#(pred)' or '.#(pred)#' leg (gjson-style)
+//	all1       the trailing '#' of a '.#(pred)#' leg, marking "match all"
+//	pred2      the predicate inside a '[?(pred)]' leg (JSONPath-style, always matches all)
+var pathExprLegRe = regexp.MustCompile(`\s*(?:` +
+	`\.\s*(?P<key>[a-zA-Z_][a-zA-Z0-9_]*|\*|"[^"\\]*(?:\\.[^"\\]*)*")` +
+	`|\[\s*(?P<idx>[0-9]+|\*)\s*\]` +
+	`|(?P<dblstar>\*\*)` +
+	`|\.?#\((?P<pred1>[^()]*)\)(?P<all1>#)?` +
+	`|\[\?\((?P<pred2>[^()]*)\)\]` +
+	`)`)
+
+// parsePathExpr parses a JSON path expression. Path expressions always start
+// with a '$', optionally followed by any number of the following legs:
+//
+//	.key           select the value for the given key of the current object
+//	.*             select the values for all keys of the current object
+//	[N]            select the N-th (0-based) element of the current array
+//	[*]            select all elements of the current array
+//	**             select all values recursively, at any depth
+//	.#(pred)       select the first array element whose subtree matches pred
+//	.#(pred)#      select every array element whose subtree matches pred
+//	[?(pred)]      JSONPath-style spelling of .#(pred)#
+func parsePathExpr(pathExpr string) (pe PathExpression, err error) {
+	pathExpr = strings.TrimSpace(pathExpr)
+	if len(pathExpr) == 0 || pathExpr[0] != '$' {
+		return pe, errors.New("Invalid JSON path expression. The error is around character position 1")
+	}
+	pathExpr = strings.TrimSpace(pathExpr[1:])
+
+	names := pathExprLegRe.SubexpNames()
+	indices := pathExprLegRe.FindAllStringSubmatchIndex(pathExpr, -1)
+	if len(indices) == 0 && len(pathExpr) != 0 {
+		return pe, errors.Errorf("Invalid JSON path expression %s", pathExpr)
+	}
+
+	group := func(indice []int, name string) (string, bool) {
+		for i, n := range names {
+			if n != name {
+				continue
+			}
+			if indice[2*i] == -1 {
+				return "", false
+			}
+			return pathExpr[indice[2*i]:indice[2*i+1]], true
+		}
+		return "", false
+	}
+
+	lastEnd := 0
+	for _, indice := range indices {
+		start, end := indice[0], indice[1]
+		if start != lastEnd {
+			return pe, errors.Errorf("Invalid JSON path expression %s", pathExpr)
+		}
+		lastEnd = end
+
+		var leg pathLeg
+		if key, ok := group(indice, "key"); ok {
+			key = strings.TrimSpace(key)
+			if len(key) > 0 && key[0] == '"' {
+				unescaped, uerr := unquoteString(key[1 : len(key)-1])
+				if uerr != nil {
+					return pe, errors.Trace(uerr)
+				}
+				key = unescaped
+			}
+			if key == "*" {
+				pe.flags |= pathExpressionContainsAsterisk
+			}
+			leg = pathLeg{typ: pathLegKey, dotKey: key}
+		} else if indexStr, ok := group(indice, "idx"); ok {
+			if indexStr == "*" {
+				pe.flags |= pathExpressionContainsAsterisk
+				leg = pathLeg{typ: pathLegIndex, arrayIndex: arrayIndexAsterisk}
+			} else {
+				index, ierr := strconv.Atoi(indexStr)
+				if ierr != nil {
+					return pe, errors.Trace(ierr)
+				}
+				leg = pathLeg{typ: pathLegIndex, arrayIndex: index}
+			}
+		} else if _, ok := group(indice, "dblstar"); ok {
+			pe.flags |= pathExpressionContainsDoubleAsterisk
+			leg = pathLeg{typ: pathLegDoubleAsterisk}
+		} else {
+			var predicate string
+			var matchAll bool
+			if pred, ok := group(indice, "pred1"); ok {
+				predicate = pred
+				_, matchAll = group(indice, "all1")
+			} else {
+				predicate, _ = group(indice, "pred2")
+				matchAll = true
+			}
+			filter, ferr := parsePathFilter(predicate, matchAll)
+			if ferr != nil {
+				return pe, errors.Trace(ferr)
+			}
+			pe.flags |= pathExpressionContainsAsterisk
+			leg = pathLeg{typ: pathLegFilter, filter: filter}
+		}
+		pe.legs = append(pe.legs, leg)
+	}
+	if lastEnd != len(pathExpr) {
+		return pe, errors.Errorf("Invalid JSON path expression %s", pathExpr)
+	}
+	return
+}
+
+var pathFilterRe = regexp.MustCompile(`^\s*(@(?:\.[a-zA-Z_][a-zA-Z0-9_]*)*|[a-zA-Z_][a-zA-Z0-9_.]*)\s*(==|!=|<=|>=|<|>)\s*(.*?)\s*$`)
+
+// parsePathFilter parses the text inside `#( ... )` into a pathFilter, e.g.
+// `category=="book"` or `@.price<10`.
+func parsePathFilter(predicate string, matchAll bool) (*pathFilter, error) {
+	sub := pathFilterRe.FindStringSubmatch(predicate)
+	if sub == nil {
+		return nil, errors.Errorf("Invalid JSON path filter expression %s", predicate)
+	}
+	keyPath := strings.TrimPrefix(sub[1], "@")
+	keyPath = strings.TrimPrefix(keyPath, ".")
+
+	var op compareOp
+	switch sub[2] {
+	case "==":
+		op = compareOpEQ
+	case "!=":
+		op = compareOpNE
+	case "<":
+		op = compareOpLT
+	case "<=":
+		op = compareOpLE
+	case ">":
+		op = compareOpGT
+	case ">=":
+		op = compareOpGE
+	}
+
+	value, err := parseFilterLiteral(sub[3])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	filter := &pathFilter{op: op, value: value, matchAll: matchAll}
+	if len(keyPath) > 0 {
+		filter.keyPath = strings.Split(keyPath, ".")
+	}
+	return filter, nil
+}
+
+// parseFilterLiteral parses the right-hand side literal of a path filter
+// predicate, e.g. `10`, `"book"`, `true` or `null`.
+func parseFilterLiteral(s string) (JSON, error) {
+	switch {
+	case s == "true":
+		return CreateJSON(true), nil
+	case s == "false":
+		return CreateJSON(false), nil
+	case s == "null":
+		return CreateJSON(nil), nil
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		unquoted, err := unquoteString(s[1 : len(s)-1])
+		if err != nil {
+			return JSON{}, errors.Trace(err)
+		}
+		return CreateJSON(unquoted), nil
+	default:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return CreateJSON(f), nil
+		}
+		return JSON{}, errors.Errorf("Invalid JSON path filter literal %s", s)
+	}
+}
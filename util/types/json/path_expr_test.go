@@ -0,0 +1,112 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import "testing"
+
+func mkObject(m map[string]JSON) JSON {
+	return JSON{typeCode: typeCodeObject, object: m}
+}
+
+func mkArray(elems ...JSON) JSON {
+	return JSON{typeCode: typeCodeArray, array: elems}
+}
+
+func mustParsePathExpr(t *testing.T, path string) PathExpression {
+	t.Helper()
+	pe, err := parsePathExpr(path)
+	if err != nil {
+		t.Fatalf("parsePathExpr(%q) returned error: %v", path, err)
+	}
+	return pe
+}
+
+func TestPathExprFilterFirstMatchOnly(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"items": mkArray(
+			mkObject(map[string]JSON{"category": CreateJSON("book"), "price": CreateJSON(8.95)}),
+			mkObject(map[string]JSON{"category": CreateJSON("book"), "price": CreateJSON(12.99)}),
+			mkObject(map[string]JSON{"category": CreateJSON("toy")}),
+		),
+	})
+	pe := mustParsePathExpr(t, `$.items.#(category=="book")`)
+	ret, found := root.Extract([]PathExpression{pe})
+	if !found {
+		t.Fatal("expected a match")
+	}
+	if ret.typeCode != typeCodeArray || len(ret.array) != 1 {
+		t.Fatalf("expected exactly the first matching book, got %+v", ret)
+	}
+	if got := ret.array[0].object["price"].f64; got != 8.95 {
+		t.Fatalf("expected the first book (price 8.95), got price %v", got)
+	}
+}
+
+func TestPathExprFilterTrailingHashMatchesAll(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"items": mkArray(
+			mkObject(map[string]JSON{"category": CreateJSON("book")}),
+			mkObject(map[string]JSON{"category": CreateJSON("book")}),
+			mkObject(map[string]JSON{"category": CreateJSON("toy")}),
+		),
+	})
+	pe := mustParsePathExpr(t, `$.items.#(category=="book")#`)
+	ret, found := root.Extract([]PathExpression{pe})
+	if !found {
+		t.Fatal("expected a match")
+	}
+	if len(ret.array) != 2 {
+		t.Fatalf("expected both books, got %+v", ret)
+	}
+}
+
+func TestPathExprFilterAtElement(t *testing.T) {
+	root := mkArray(CreateJSON(5.0), CreateJSON(15.0))
+	// bare '@' compares the array element itself.
+	pe := mustParsePathExpr(t, `$.#(@<10)`)
+	ret, found := root.Extract([]PathExpression{pe})
+	if !found || len(ret.array) != 1 || ret.array[0].f64 != 5.0 {
+		t.Fatalf("expected [5.0], got found=%v ret=%+v", found, ret)
+	}
+}
+
+func TestPathExprFilterAtKeyPath(t *testing.T) {
+	root := mkObject(map[string]JSON{
+		"items": mkArray(
+			mkObject(map[string]JSON{"price": CreateJSON(5.0)}),
+			mkObject(map[string]JSON{"price": CreateJSON(15.0)}),
+		),
+	})
+	// the JSONPath-style '[?(@.field<value)]' spelling.
+	pe := mustParsePathExpr(t, `$.items[?(@.price<10)]`)
+	ret, found := root.Extract([]PathExpression{pe})
+	if !found || len(ret.array) != 1 || ret.array[0].object["price"].f64 != 5.0 {
+		t.Fatalf("expected the cheap item only, got found=%v ret=%+v", found, ret)
+	}
+
+	// the gjson-style '.#(@.field<value)' spelling of the same predicate.
+	pe2 := mustParsePathExpr(t, `$.items.#(@.price<10)`)
+	ret2, found2 := root.Extract([]PathExpression{pe2})
+	if !found2 || len(ret2.array) != 1 || ret2.array[0].object["price"].f64 != 5.0 {
+		t.Fatalf("expected the cheap item only, got found=%v ret=%+v", found2, ret2)
+	}
+}
+
+func TestPathExprFilterRejectedBySetInsertReplace(t *testing.T) {
+	pe := mustParsePathExpr(t, `$.items.#(category=="book")`)
+	j := mkObject(map[string]JSON{"items": mkArray()})
+	if err := j.SetInsertReplace([]PathExpression{pe}, []JSON{CreateJSON(nil)}, ModifySet); err == nil {
+		t.Fatal("expected SetInsertReplace to reject a filter leg like an asterisk")
+	}
+}